@@ -1,19 +1,153 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/rsmaxwell/page/internal/version"
 
 	"github.com/rsmaxwell/page/internal/config"
+	"github.com/rsmaxwell/page/internal/pattern"
+	"github.com/rsmaxwell/page/internal/safepath"
 )
 
+// pageIgnoreFile is the name of a per-directory ignore file, in the
+// style of .gitignore.
+const pageIgnoreFile = ".pageignore"
+
+// loadMatcher builds the pattern.Matcher that applies to prefixDirectory,
+// by combining defaults with every .pageignore file found walking up from
+// prefixDirectory to root (inclusive). Patterns from nearer directories
+// are appended last, so they win ties over patterns from parent
+// directories and over defaults.
+func loadMatcher(root string, prefixDirectory string, defaults []string) pattern.Matcher {
+
+	var dirs []string
+	dir := prefixDirectory
+	for {
+		dirs = append([]string{dir}, dirs...)
+		if dir == root {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	lines := append([]string{}, defaults...)
+	for _, dir := range dirs {
+		data, err := ioutil.ReadFile(filepath.Join(dir, pageIgnoreFile))
+		if err != nil {
+			continue
+		}
+		lines = append(lines, strings.Split(string(data), "\n")...)
+	}
+
+	return pattern.NewMatcher(pattern.ParsePatterns(lines))
+}
+
+// thumbnailURL builds the pageserver URL for a resized derivative of
+// filename at the given width.
+func thumbnailURL(filename string, width int) string {
+	v := url.Values{}
+	v.Set("image", filename)
+	v.Set("width", strconv.Itoa(width))
+	return "pageserver/thumbnail?" + v.Encode()
+}
+
+// srcset builds the value of an <img> srcset attribute, offering filename
+// at every one of widths.
+func srcset(filename string, widths []int) string {
+	parts := make([]string, 0, len(widths))
+	for _, width := range widths {
+		parts = append(parts, fmt.Sprintf("%s %dw", thumbnailURL(filename, width), width))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeTar streams every file in filelist, found under dir, to w as an
+// uncompressed tar archive, without buffering file contents in memory.
+func writeTar(w io.Writer, dir string, filelist []os.FileInfo) error {
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	for _, file := range filelist {
+		if file.IsDir() {
+			continue
+		}
+
+		header, err := tar.FileInfoHeader(file, "")
+		if err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if err := copyFile(tw, filepath.Join(dir, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// writeZip streams every file in filelist, found under dir, to w as a zip
+// archive, without buffering file contents in memory.
+func writeZip(w io.Writer, dir string, filelist []os.FileInfo) error {
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	for _, file := range filelist {
+		if file.IsDir() {
+			continue
+		}
+
+		header, err := zip.FileInfoHeader(file)
+		if err != nil {
+			return err
+		}
+		header.Method = zip.Store
+
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if err := copyFile(entry, filepath.Join(dir, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// copyFile streams the contents of path to w.
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -23,9 +157,35 @@ func contains(s []string, e string) bool {
 	return false
 }
 
-func main() {
+// exportAlbum streams every file in filelist, found under dir, to stdout
+// as a single tar or zip archive instead of the HTML viewer. "tar-"
+// matches BuildKit's "-" dest convention: stream to stdout with no
+// Content-Disposition, for CLI piping.
+func exportAlbum(export string, dir string, filelist []os.FileInfo) {
 
-	fmt.Printf("Content-type: text/html\n\n")
+	album := filepath.Base(dir)
+
+	switch export {
+	case "tar", "tar-":
+		fmt.Printf("Content-type: application/x-tar\n")
+		if export == "tar" {
+			fmt.Printf("Content-Disposition: attachment; filename=\"%s.tar\"\n", album)
+		}
+		fmt.Printf("\n")
+		if err := writeTar(os.Stdout, dir, filelist); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write tar export: "+err.Error()+"\n")
+		}
+	case "zip":
+		fmt.Printf("Content-type: application/zip\n")
+		fmt.Printf("Content-Disposition: attachment; filename=\"%s.zip\"\n", album)
+		fmt.Printf("\n")
+		if err := writeZip(os.Stdout, dir, filelist); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write zip export: "+err.Error()+"\n")
+		}
+	}
+}
+
+func main() {
 
 	config := config.New()
 	fmt.Fprintf(os.Stderr, "config.Prefix:"+config.Prefix+"\n")
@@ -58,6 +218,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "too many zooms: "+strings.Join(zooms, ",")+"\n")
 	}
 
+	exports := q["export"]
+	export := ""
+	if len(exports) == 1 {
+		export = strings.ToLower(exports[0])
+	} else if len(exports) > 1 {
+		fmt.Fprintf(os.Stderr, "too many exports: "+strings.Join(exports, ",")+"\n")
+	}
+
 	files := q["image"]
 	if len(files) < 1 {
 		fmt.Fprintf(os.Stderr, "no files: "+requestURI+"\n")
@@ -69,6 +237,11 @@ func main() {
 	filename := files[0]
 
 	imagefile := filepath.Join(config.Prefix, filename)
+	if !safepath.UnderRoot(config.Prefix, imagefile) {
+		fmt.Fprintf(os.Stderr, "invalid file: "+imagefile+", prefix: "+config.Prefix+", filename: "+filename+"\n")
+		os.Exit(1)
+	}
+
 	_, err = os.Stat(imagefile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "could not stat file: "+imagefile+", prefix: "+config.Prefix+", filename: "+filename+"\n")
@@ -82,13 +255,27 @@ func main() {
 		os.Exit(1)
 	}
 
-	// list the files with the same parent, sorted by name
+	// list the files with the same parent, sorted by name, honouring any
+	// .pageignore files from config.Prefix down to prefixDirectory
+	matcher := loadMatcher(config.Prefix, prefixDirectory, config.DefaultIgnorePatterns)
+
 	validExtensions := []string{".jpg", ".jpeg", ".png"}
 	var filelist = make([]os.FileInfo, 0)
 	for _, child := range children {
-		extension := filepath.Ext(child.Name())
-		if contains(validExtensions, strings.ToLower(extension)) {
+		if child.IsDir() {
+			continue
+		}
+
+		switch matcher.Match([]string{child.Name()}, child.IsDir()) {
+		case pattern.Exclude:
+			continue
+		case pattern.Include:
 			filelist = append(filelist, child)
+		default:
+			extension := filepath.Ext(child.Name())
+			if contains(validExtensions, strings.ToLower(extension)) {
+				filelist = append(filelist, child)
+			}
 		}
 	}
 
@@ -96,6 +283,13 @@ func main() {
 		return filelist[i].Name() < filelist[j].Name()
 	})
 
+	if export == "tar" || export == "tar-" || export == "zip" {
+		exportAlbum(export, prefixDirectory, filelist)
+		return
+	}
+
+	fmt.Printf("Content-type: text/html\n\n")
+
 	found := -1
 	for i, f := range filelist {
 		if filepath.Base(filename) == f.Name() {
@@ -133,7 +327,7 @@ func main() {
 	image := ""
 	if zoom == "scale" {
 		zoomButton = " <div class=\"top-center\"><img src=\"images/minus.png\"></div> \n"
-		image = " <img src=\"" + imagefile + "\" class=\"center-fit\" > \n"
+		image = " <img src=\"" + thumbnailURL(filename, config.Widths[0]) + "\" srcset=\"" + srcset(filename, config.Widths) + "\" class=\"center-fit\" > \n"
 	} else {
 		zoomButton = " <div class=\"top-center\"><img src=\"images/plus.png\"></div> \n"
 		image = " <img src=\"" + imagefile + "\" class=\"center-fit\" > \n"
@@ -0,0 +1,112 @@
+// Command pageserver is a long-running HTTP server that serves resized,
+// cached derivatives of the images under config.Prefix, so that the
+// "scale" zoom mode in the page viewer actually delivers a smaller image.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rsmaxwell/page/internal/config"
+	"github.com/rsmaxwell/page/internal/debug"
+	"github.com/rsmaxwell/page/internal/imagecache"
+	"github.com/rsmaxwell/page/internal/safepath"
+)
+
+const (
+	maxCacheBytes   = 512 * 1024 * 1024
+	maxCacheEntries = 10000
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	debugAddr := flag.String("debug-addr", "127.0.0.1:8082", "localhost-only address to serve /debug/events on")
+	flag.Parse()
+
+	config := config.New()
+	debug.Open(config.Debug)
+
+	cache, err := imagecache.NewCache(config.Prefix, config.Widths, maxCacheBytes, maxCacheEntries)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/thumbnail", func(w http.ResponseWriter, r *http.Request) {
+		serveThumbnail(w, r, config.Prefix, cache)
+	})
+
+	// /debug/events streams every logged call server-wide, including
+	// request headers DebugRequest doesn't redact, so it gets its own
+	// listener bound to localhost rather than a route on the public mux.
+	debugMux := http.NewServeMux()
+	debugMux.Handle("/debug/events", debug.Events())
+	go func() {
+		log.Printf("debug events listening on %s (localhost-only)", *debugAddr)
+		log.Fatal(http.ListenAndServe(*debugAddr, debugMux))
+	}()
+
+	log.Printf("pageserver listening on %s, prefix: %s", *addr, config.Prefix)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// serveThumbnail serves the cached, resized derivative of the "image"
+// query parameter at the requested "width", honouring If-None-Match.
+func serveThumbnail(w http.ResponseWriter, r *http.Request, prefix string, cache *imagecache.Cache) {
+
+	image := r.URL.Query().Get("image")
+	if image == "" {
+		http.Error(w, "missing image parameter", http.StatusBadRequest)
+		return
+	}
+
+	width, err := strconv.Atoi(r.URL.Query().Get("width"))
+	if err != nil || width <= 0 {
+		width = cache.DefaultWidth()
+	}
+
+	srcPath := filepath.Join(prefix, image)
+	if !safepath.UnderRoot(prefix, srcPath) {
+		http.Error(w, "invalid image parameter", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	derivative, err := cache.Get(srcPath, width)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	quoted := strconv.Quote(derivative.ETag)
+	w.Header().Set("ETag", quoted)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == quoted {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	f, err := os.Open(derivative.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", derivative.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	http.ServeContent(w, r, filepath.Base(derivative.Path), info.ModTime(), f)
+}
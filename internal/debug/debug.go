@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"runtime/debug"
@@ -65,6 +66,11 @@ var (
 	dumpRoot             string
 	functionLevels       map[string]int
 	packageLevels        map[string]int
+	dumpStore            *Store
+	encoder              Encoder
+	redactFields         map[string]bool
+	redactPatterns       []*regexp.Regexp
+	hub                  *Hub
 )
 
 // Open function
@@ -85,6 +91,38 @@ func Open(c config.Debug) {
 		log.Println(err)
 	}
 	logger = log.New(file, "page", log.LstdFlags)
+
+	switch strings.ToLower(c.Format) {
+	case "json":
+		encoder = NewJSONEncoder(logger)
+	default:
+		encoder = NewTextEncoder(logger)
+	}
+
+	redactFields = map[string]bool{}
+	for _, name := range c.RedactFields {
+		redactFields[strings.ToLower(name)] = true
+	}
+	if len(redactFields) == 0 {
+		redactFields["password"] = true
+	}
+
+	redactPatterns = nil
+	for _, pattern := range c.RedactPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		redactPatterns = append(redactPatterns, re)
+	}
+
+	dumpStore, err = NewBoundedStore(dumpRoot, c.MaxDumpBytes, c.MaxDumpCount)
+	if err != nil {
+		log.Println(err)
+	}
+
+	hub = NewHub()
 }
 
 // Close function
@@ -180,36 +218,55 @@ func (f *Function) Fatalf(format string, a ...interface{}) {
 
 // Debug prints the function name
 func (f *Function) Debug(l int, format string, a ...interface{}) {
-	if l <= level {
-		if l <= f.pkg.level {
-			if l <= f.level {
-				line1 := fmt.Sprintf(format, a...)
-				line2 := fmt.Sprintf("%s.%s %s", f.pkg.name, f.name, line1)
-				logger.Printf(line2)
-			}
-		}
+	if l <= level && l <= f.pkg.level && l <= f.level {
+		f.emit(l, true, format, a...)
 	}
 }
 
 // Printf prints a debug message
 func (f *Function) Printf(l int, format string, a ...interface{}) {
-	if l <= level {
-		if l <= f.pkg.level {
-			if l <= f.level {
-				logger.Printf(format, a...)
-			}
-		}
+	if l <= level && l <= f.pkg.level && l <= f.level {
+		f.emit(l, false, format, a...)
 	}
 }
 
 // Println prints a debug message
 func (f *Function) Println(l int, format string, a ...interface{}) {
-	if l <= level {
-		if l <= f.pkg.level {
-			if l <= f.level {
-				logger.Println(fmt.Sprintf(format, a...))
-			}
-		}
+	if l <= level && l <= f.pkg.level && l <= f.level {
+		f.emit(l, false, format, a...)
+	}
+}
+
+// emit builds a Record for this call and hands it to the configured
+// Encoder. prefixed matches the original text format: Debug prefixes the
+// line with "<package>.<function>", Printf/Println do not.
+func (f *Function) emit(l int, prefixed bool, format string, a ...interface{}) {
+
+	_, file, line, _ := runtime.Caller(2)
+
+	record := Record{
+		Time:     time.Now(),
+		Level:    levelName(l),
+		Package:  f.pkg.name,
+		Function: f.name,
+		Message:  fmt.Sprintf(format, a...),
+		File:     file,
+		Line:     line,
+		Prefixed: prefixed,
+	}
+
+	if err := encoder.Encode(record); err != nil {
+		log.Println(err)
+	}
+
+	if hub != nil {
+		hub.publish(Event{
+			Time:     record.Time,
+			Level:    record.Level,
+			Package:  record.Package,
+			Function: record.Function,
+			Message:  record.Message,
+		})
 	}
 }
 
@@ -252,31 +309,62 @@ func (f *Function) DebugRequest(req *http.Request) {
 func (f *Function) DebugRequestBody(data []byte) {
 
 	if f.Level() >= APILevel {
-		text1 := string(data) // multi-line json
+		text := string(data) // multi-line json
 
-		space := regexp.MustCompile(`\s+`)
-		text2 := space.ReplaceAllString(text1, " ") // may contain a 'password' field
-
-		text3 := text2
 		var m map[string]interface{}
-		err := json.Unmarshal([]byte(text2), &m)
-		if err == nil {
-			text3 = "{ "
-			sep := ""
-			for k, v := range m {
-				v2 := v
-				if strings.ToLower(k) == "password" {
-					v2 = interface{}("********")
-				}
-				text3 = fmt.Sprintf("%s%s\"%s\": \"%s\"", text3, sep, k, v2)
-				sep = ", "
+		if err := json.Unmarshal(data, &m); err == nil {
+			if out, err := json.Marshal(redact(m)); err == nil {
+				text = string(out)
 			}
-			text3 = text3 + " }"
+		} else {
+			space := regexp.MustCompile(`\s+`)
+			text = space.ReplaceAllString(text, " ")
 		}
-		f.DebugAPI("request body: %s", text3) // sanitised!
+
+		f.DebugAPI("request body: %s", text) // sanitised!
 	}
 }
 
+// redact walks value, replacing any object field whose name matches
+// redactFields or redactPatterns with "********", recursing into nested
+// objects and arrays.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if isRedactedField(key) {
+				redacted[key] = "********"
+			} else {
+				redacted[key] = redact(val)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, val := range v {
+			redacted[i] = redact(val)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// isRedactedField reports whether key should be redacted, per the
+// configured field names and regex patterns.
+func isRedactedField(key string) bool {
+	if redactFields[strings.ToLower(key)] {
+		return true
+	}
+	for _, re := range redactPatterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
 // Dump type
 type Dump struct {
 	directory string
@@ -375,6 +463,21 @@ func (f *Function) Dump(format string, a ...interface{}) *Dump {
 		return dump
 	}
 
+	if dumpStore != nil {
+		dumpStore.add(dump.directory, info.TimeUnixNano)
+	}
+
+	if hub != nil {
+		hub.publish(Event{
+			Time:     t,
+			Level:    levelName(ErrorLevel),
+			Package:  f.pkg.name,
+			Function: f.name,
+			Message:  info.Message,
+			DumpDir:  dump.directory,
+		})
+	}
+
 	return dump
 }
 
@@ -406,6 +509,14 @@ type MarkDumps struct {
 func Mark() *MarkDumps {
 
 	mark := new(MarkDumps)
+	mark.dumps = map[string]bool{}
+
+	if dumpStore != nil {
+		for _, dump := range dumpStore.list() {
+			mark.dumps[filepath.Base(dump.directory)] = true
+		}
+		return mark
+	}
 
 	files, err := ioutil.ReadDir(dumpRoot)
 	if err != nil {
@@ -413,8 +524,6 @@ func Mark() *MarkDumps {
 		return mark
 	}
 
-	mark.dumps = map[string]bool{}
-
 	for _, file := range files {
 		if file.IsDir() {
 			mark.dumps[file.Name()] = true
@@ -431,7 +540,7 @@ func (mark *MarkDumps) ListNewDumps() ([]*Dump, error) {
 		return nil, mark.err
 	}
 
-	files, err := ioutil.ReadDir(dumpRoot)
+	all, err := ListDumps()
 	if err != nil {
 		mark.err = err
 		return nil, err
@@ -439,15 +548,9 @@ func (mark *MarkDumps) ListNewDumps() ([]*Dump, error) {
 
 	newdumps := []*Dump{}
 
-	for _, file := range files {
-		if file.IsDir() {
-			if !mark.dumps[file.Name()] {
-
-				dump := new(Dump)
-				dump.directory = dumpRoot + "/" + file.Name()
-
-				newdumps = append(newdumps, dump)
-			}
+	for _, dump := range all {
+		if !mark.dumps[filepath.Base(dump.directory)] {
+			newdumps = append(newdumps, dump)
 		}
 	}
 
@@ -457,6 +560,10 @@ func (mark *MarkDumps) ListNewDumps() ([]*Dump, error) {
 // ListDumps method
 func ListDumps() ([]*Dump, error) {
 
+	if dumpStore != nil {
+		return dumpStore.list(), nil
+	}
+
 	files, err := ioutil.ReadDir(dumpRoot)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,117 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rsmaxwell/page/internal/version"
+)
+
+// Record is a single log line passed to an Encoder.
+type Record struct {
+	Time     time.Time
+	Level    string
+	Package  string
+	Function string
+	Message  string
+	File     string
+	Line     int
+
+	// Prefixed is true for Function.Debug calls, which prefix the text
+	// line with "<package>.<function>"; Printf/Println leave it false.
+	Prefixed bool
+}
+
+// Encoder writes a Record to the log.
+type Encoder interface {
+	Encode(r Record) error
+}
+
+// levelName returns the string form of a trace level, as used by
+// structured encoders.
+func levelName(l int) string {
+	switch l {
+	case ErrorLevel:
+		return "error"
+	case WarningLevel:
+		return "warn"
+	case InfoLevel:
+		return "info"
+	case APILevel:
+		return "api"
+	case VerboseLevel:
+		return "verbose"
+	default:
+		return "unknown"
+	}
+}
+
+// TextEncoder writes a Record as a single plain-text line, matching the
+// original "<package>.<function> <message>" format.
+type TextEncoder struct {
+	logger *log.Logger
+}
+
+// NewTextEncoder returns an Encoder that writes plain-text lines to logger.
+func NewTextEncoder(logger *log.Logger) *TextEncoder {
+	return &TextEncoder{logger: logger}
+}
+
+// Encode writes r as a plain-text line, matching the original
+// Debug/Printf/Println formats.
+func (e *TextEncoder) Encode(r Record) error {
+	if r.Prefixed {
+		e.logger.Printf("%s.%s %s", r.Package, r.Function, r.Message)
+	} else {
+		e.logger.Print(r.Message)
+	}
+	return nil
+}
+
+// jsonRecord is the on-the-wire shape written by JSONEncoder.
+type jsonRecord struct {
+	Time      string `json:"ts"`
+	Level     string `json:"level"`
+	Package   string `json:"package"`
+	Function  string `json:"function"`
+	Message   string `json:"msg"`
+	Caller    string `json:"caller"`
+	Version   string `json:"version"`
+	GitCommit string `json:"gitcommit"`
+}
+
+// JSONEncoder writes a Record as one JSON object per line.
+type JSONEncoder struct {
+	logger *log.Logger
+}
+
+// NewJSONEncoder returns an Encoder that writes one JSON object per line
+// to logger.
+func NewJSONEncoder(logger *log.Logger) *JSONEncoder {
+	return &JSONEncoder{logger: logger}
+}
+
+// Encode writes r as a single JSON object.
+func (e *JSONEncoder) Encode(r Record) error {
+
+	jr := jsonRecord{
+		Time:      r.Time.Format(time.RFC3339Nano),
+		Level:     r.Level,
+		Package:   r.Package,
+		Function:  r.Function,
+		Message:   r.Message,
+		Caller:    fmt.Sprintf("%s:%d", r.File, r.Line),
+		Version:   version.Version(),
+		GitCommit: version.GitCommit(),
+	}
+
+	data, err := json.Marshal(jr)
+	if err != nil {
+		return err
+	}
+
+	e.logger.Println(string(data))
+	return nil
+}
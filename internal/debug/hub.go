@@ -0,0 +1,162 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subscriberBufferSize bounds how far a subscriber may lag before events
+// are dropped rather than blocking the publisher.
+const subscriberBufferSize = 64
+
+// heartbeatInterval is how often ServeHTTP writes a keep-alive comment to
+// an idle SSE stream.
+const heartbeatInterval = 15 * time.Second
+
+// Event is published for every Function.Debug/Println/Printf/Dump call.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Package  string    `json:"package"`
+	Function string    `json:"function"`
+	Message  string    `json:"message"`
+	DumpDir  string    `json:"dumpDir,omitempty"`
+}
+
+// Hub fans out Events to live subscribers, e.g. for a Server-Sent Events
+// stream of the trace log.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]*subscriber
+}
+
+// subscriber is a single Hub subscriber's buffered channel, plus a count
+// of events dropped because the subscriber was too slow to keep up.
+type subscriber struct {
+	ch      chan Event
+	dropped int64
+}
+
+// NewHub returns an empty Hub.
+func NewHub() *Hub {
+	return &Hub{subscribers: map[chan Event]*subscriber{}}
+}
+
+// Subscribe registers a new subscriber and returns its event channel and
+// an unsubscribe function. The caller must call unsubscribe once done.
+func (h *Hub) Subscribe() (<-chan Event, func() error) {
+
+	ch := make(chan Event, subscriberBufferSize)
+
+	h.mu.Lock()
+	h.subscribers[ch] = &subscriber{ch: ch}
+	h.mu.Unlock()
+
+	unsubscribe := func() error {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+		return nil
+	}
+
+	return ch, unsubscribe
+}
+
+// publish fans event out to every live subscriber, dropping it for any
+// subscriber whose buffer is full rather than blocking.
+func (h *Hub) publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, sub := range h.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// levelValue maps a level name back to its numeric trace level, so that
+// a "?minLevel=" query can be compared against Event.Level.
+func levelValue(name string) int {
+	switch strings.ToLower(name) {
+	case "error":
+		return ErrorLevel
+	case "warn", "warning":
+		return WarningLevel
+	case "info":
+		return InfoLevel
+	case "api":
+		return APILevel
+	case "verbose":
+		return VerboseLevel
+	default:
+		return VerboseLevel
+	}
+}
+
+// ServeHTTP streams live Events as Server-Sent Events, most-important
+// first, filtered by the optional "?minLevel=" query parameter.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	minLevel := VerboseLevel
+	if q := r.URL.Query().Get("minLevel"); q != "" {
+		minLevel = levelValue(q)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if levelValue(event.Level) > minLevel {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Events returns the Hub that every Function.Debug/Println/Printf/Dump
+// call publishes to.
+func Events() *Hub {
+	return hub
+}
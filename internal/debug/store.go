@@ -0,0 +1,163 @@
+package debug
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store bounds the dump directories kept under root, evicting the oldest
+// of them once maxBytes or maxCount is exceeded.
+type Store struct {
+	root     string
+	maxBytes int64
+	maxCount int
+
+	mu      sync.Mutex
+	entries []*storeEntry
+	pinned  map[string]bool
+}
+
+// storeEntry records the on-disk size and creation time of a single dump.
+type storeEntry struct {
+	dir      string
+	bytes    int64
+	timeNano int64
+}
+
+// NewBoundedStore scans the dumps already under root and returns a Store
+// that evicts the oldest of them once maxBytes or maxCount is exceeded.
+func NewBoundedStore(root string, maxBytes int64, maxCount int) (*Store, error) {
+
+	s := &Store{
+		root:     root,
+		maxBytes: maxBytes,
+		maxCount: maxCount,
+		pinned:   map[string]bool{},
+	}
+
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		dir := root + "/" + file.Name()
+
+		size, err := dirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		timeNano := file.ModTime().UnixNano()
+		dump := &Dump{directory: dir}
+		if info, err := dump.GetInfo(); err == nil {
+			timeNano = info.TimeUnixNano
+		}
+
+		s.entries = append(s.entries, &storeEntry{dir: dir, bytes: size, timeNano: timeNano})
+	}
+
+	s.evictLocked()
+
+	return s, nil
+}
+
+// dirSize returns the total size in bytes of all files under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// add registers a newly-written dump with the store and evicts the
+// oldest dumps until both caps are satisfied.
+func (s *Store) add(dir string, timeNano int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size, err := dirSize(dir)
+	if err != nil {
+		size = 0
+	}
+
+	s.entries = append(s.entries, &storeEntry{dir: dir, bytes: size, timeNano: timeNano})
+	s.evictLocked()
+}
+
+// Pin prevents dir from being evicted, e.g. while it is still being
+// investigated.
+func (s *Store) Pin(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pinned[dir] = true
+}
+
+// Stats returns the current total size and number of dumps held by the
+// store.
+func (s *Store) Stats() (bytes int64, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		bytes += e.bytes
+	}
+	return bytes, len(s.entries)
+}
+
+// list returns every dump currently tracked by the store.
+func (s *Store) list() []*Dump {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dumps := make([]*Dump, 0, len(s.entries))
+	for _, e := range s.entries {
+		dumps = append(dumps, &Dump{directory: e.dir})
+	}
+	return dumps
+}
+
+// evictLocked removes the oldest, unpinned dumps until both maxBytes and
+// maxCount are satisfied. Callers must hold s.mu.
+func (s *Store) evictLocked() {
+
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].timeNano < s.entries[j].timeNano
+	})
+
+	var total int64
+	for _, e := range s.entries {
+		total += e.bytes
+	}
+	count := len(s.entries)
+
+	remaining := make([]*storeEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if (total > s.maxBytes || count > s.maxCount) && !s.pinned[e.dir] {
+			if err := os.RemoveAll(e.dir); err == nil {
+				total -= e.bytes
+				count--
+				continue
+			}
+		}
+		remaining = append(remaining, e)
+	}
+	s.entries = remaining
+}
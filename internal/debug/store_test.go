@@ -0,0 +1,130 @@
+package debug
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeDump creates a dump directory under root containing a single file
+// of size bytes, with its modification time set to base.
+func makeDump(t *testing.T, root string, name string, base time.Time, size int) string {
+	t.Helper()
+
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dir, base, base); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestNewBoundedStoreOrdersAndEvictsOldest(t *testing.T) {
+	root, err := ioutil.TempDir("", "store-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	base := time.Unix(1700000000, 0)
+	dirA := makeDump(t, root, "a", base, 10)
+	makeDump(t, root, "b", base.Add(1*time.Second), 10)
+	makeDump(t, root, "c", base.Add(2*time.Second), 10)
+
+	store, err := NewBoundedStore(root, 1<<30, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, count := store.Stats()
+	if count != 2 {
+		t.Fatalf("expected 2 dumps to survive, got %d", count)
+	}
+	if bytes != 20 {
+		t.Fatalf("expected 20 bytes to survive, got %d", bytes)
+	}
+
+	if _, err := os.Stat(dirA); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest dump %q to be evicted", dirA)
+	}
+}
+
+func TestStoreAddEvictsByMaxBytes(t *testing.T) {
+	root, err := ioutil.TempDir("", "store-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := &Store{root: root, maxBytes: 15, maxCount: 100, pinned: map[string]bool{}}
+
+	dirA := filepath.Join(root, "a")
+	if err := os.MkdirAll(dirA, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirA, "data"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.add(dirA, 1)
+
+	dirB := filepath.Join(root, "b")
+	if err := os.MkdirAll(dirB, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirB, "data"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.add(dirB, 2)
+
+	bytes, count := store.Stats()
+	if count != 1 {
+		t.Fatalf("expected 1 dump after eviction, got %d", count)
+	}
+	if bytes != 10 {
+		t.Fatalf("expected 10 bytes remaining, got %d", bytes)
+	}
+	if _, err := os.Stat(dirA); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest dump %q to be evicted once maxBytes was exceeded", dirA)
+	}
+}
+
+func TestStorePinPreventsEviction(t *testing.T) {
+	root, err := ioutil.TempDir("", "store-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := &Store{root: root, maxBytes: 1, maxCount: 1, pinned: map[string]bool{}}
+
+	dirA := filepath.Join(root, "a")
+	if err := os.MkdirAll(dirA, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirA, "data"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.add(dirA, 1)
+	store.Pin(dirA)
+
+	dirB := filepath.Join(root, "b")
+	if err := os.MkdirAll(dirB, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirB, "data"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.add(dirB, 2)
+
+	if _, err := os.Stat(dirA); err != nil {
+		t.Fatalf("expected pinned dump %q to survive eviction: %v", dirA, err)
+	}
+}
@@ -0,0 +1,253 @@
+// Package imagecache resizes source images on demand and caches the
+// derivatives on disk under "<prefix>/.cache/<width>/<hash>.jpg" so that
+// repeat requests avoid the decode+scale cost.
+package imagecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// Widths are the default derivative widths used when a Cache is
+// constructed with no widths of its own, in ascending order.
+var Widths = []int{480, 1024, 2048}
+
+// JpegQuality is used when encoding cached derivatives.
+const JpegQuality = 85
+
+// Cache resizes and caches derivative JPEGs of the images under Prefix,
+// evicting the oldest derivatives once MaxBytes or MaxEntries is exceeded.
+type Cache struct {
+	Prefix     string
+	Widths     []int
+	MaxBytes   int64
+	MaxEntries int
+
+	// locks serializes concurrent Get calls racing the same cache miss,
+	// keyed by cachePath, so two requests for the same uncached
+	// derivative can't both resize into the same ".tmp" file at once.
+	locks sync.Map // map[string]*sync.Mutex
+
+	mu      sync.Mutex
+	entries []*cacheEntry
+}
+
+// cacheEntry records the on-disk size and modification time of a single
+// cached derivative.
+type cacheEntry struct {
+	path    string
+	bytes   int64
+	modNano int64
+}
+
+// NewCache returns a Cache rooted at prefix, resizing to widths (falling
+// back to the package default Widths when none are given). The
+// derivatives already cached under "<prefix>/.cache" are scanned once up
+// front, so later evictions don't need to re-walk the disk.
+func NewCache(prefix string, widths []int, maxBytes int64, maxEntries int) (*Cache, error) {
+	if len(widths) == 0 {
+		widths = Widths
+	}
+	c := &Cache{Prefix: prefix, Widths: widths, MaxBytes: maxBytes, MaxEntries: maxEntries}
+
+	root := filepath.Join(prefix, ".cache")
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		c.entries = append(c.entries, &cacheEntry{path: path, bytes: info.Size(), modNano: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	c.evictLocked()
+	return c, nil
+}
+
+// lockFor returns the mutex guarding cache population for key, creating
+// one on first use.
+func (c *Cache) lockFor(key string) *sync.Mutex {
+	actual, _ := c.locks.LoadOrStore(key, &sync.Mutex{})
+	return actual.(*sync.Mutex)
+}
+
+// DefaultWidth is the narrowest derivative width the cache serves.
+func (c *Cache) DefaultWidth() int {
+	return c.Widths[0]
+}
+
+// Derivative describes a cached, resized copy of a source image.
+type Derivative struct {
+	Path        string
+	ContentType string
+	ETag        string
+}
+
+// nearestWidth returns the smallest of c.Widths that is >= width,
+// falling back to the largest configured width.
+func (c *Cache) nearestWidth(width int) int {
+	for _, w := range c.Widths {
+		if width <= w {
+			return w
+		}
+	}
+	return c.Widths[len(c.Widths)-1]
+}
+
+func (c *Cache) dir(width int) string {
+	return filepath.Join(c.Prefix, ".cache", fmt.Sprintf("%d", width))
+}
+
+// etag is the sha256 of the source file's path, size and modification
+// time, together with the derivative width.
+func etag(srcPath string, width int) (string, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%d:%d:%d", srcPath, info.Size(), info.ModTime().UnixNano(), width)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the cached derivative of srcPath at the requested width,
+// resizing and caching it first if necessary.
+func (c *Cache) Get(srcPath string, width int) (*Derivative, error) {
+
+	width = c.nearestWidth(width)
+
+	tag, err := etag(srcPath, width)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := c.dir(width)
+	cachePath := filepath.Join(dir, tag+".jpg")
+
+	if _, err := os.Stat(cachePath); err == nil {
+		return &Derivative{Path: cachePath, ContentType: "image/jpeg", ETag: tag}, nil
+	}
+
+	mu := c.lockFor(cachePath)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Re-check now that we hold the lock: another goroutine may have
+	// populated the cache while we were waiting for it.
+	if _, err := os.Stat(cachePath); err == nil {
+		return &Derivative{Path: cachePath, ContentType: "image/jpeg", ETag: tag}, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := resize(srcPath, cachePath, width); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries = append(c.entries, &cacheEntry{path: cachePath, bytes: info.Size(), modNano: info.ModTime().UnixNano()})
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return &Derivative{Path: cachePath, ContentType: "image/jpeg", ETag: tag}, nil
+}
+
+// resize decodes src, scales it to width (preserving aspect ratio) using
+// a bilinear filter, and writes the result to dst as a JPEG.
+func resize(src string, dst string, width int) error {
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	srcImage, _, err := image.Decode(in)
+	if err != nil {
+		return err
+	}
+
+	srcBounds := srcImage.Bounds()
+	if srcBounds.Dx() <= width {
+		width = srcBounds.Dx()
+	}
+	height := srcBounds.Dy() * width / srcBounds.Dx()
+
+	dstImage := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dstImage, dstImage.Bounds(), srcImage, srcBounds, draw.Over, nil)
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := jpeg.Encode(out, dstImage, &jpeg.Options{Quality: JpegQuality}); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// evictLocked removes the oldest cached derivatives, tracked in memory
+// since NewCache, until both MaxBytes and MaxEntries are satisfied.
+// Callers must hold c.mu.
+func (c *Cache) evictLocked() {
+
+	sort.Slice(c.entries, func(i, j int) bool {
+		return c.entries[i].modNano < c.entries[j].modNano
+	})
+
+	var total int64
+	for _, e := range c.entries {
+		total += e.bytes
+	}
+	count := len(c.entries)
+
+	remaining := make([]*cacheEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		if total > c.MaxBytes || count > c.MaxEntries {
+			if err := os.Remove(e.path); err == nil {
+				total -= e.bytes
+				count--
+				continue
+			}
+		}
+		remaining = append(remaining, e)
+	}
+	c.entries = remaining
+}
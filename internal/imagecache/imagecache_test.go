@@ -0,0 +1,110 @@
+package imagecache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheNearestWidth(t *testing.T) {
+	c := &Cache{Widths: []int{480, 1024, 2048}}
+
+	tests := []struct {
+		name  string
+		width int
+		want  int
+	}{
+		{name: "below the smallest width rounds up to it", width: 100, want: 480},
+		{name: "exactly a configured width returns it unchanged", width: 1024, want: 1024},
+		{name: "between two widths rounds up to the larger", width: 1025, want: 2048},
+		{name: "above the largest width falls back to the largest", width: 4000, want: 2048},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.nearestWidth(tt.width); got != tt.want {
+				t.Errorf("nearestWidth(%d) = %d, want %d", tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+// makeDerivative creates a cached derivative file of size bytes under
+// root, with its modification time set to modTime.
+func makeDerivative(t *testing.T, root string, name string, modTime time.Time, size int) string {
+	t.Helper()
+
+	path := filepath.Join(root, name)
+	if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCacheEvictLockedByMaxBytes(t *testing.T) {
+	root, err := ioutil.TempDir("", "imagecache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	base := time.Unix(1700000000, 0)
+	pathA := makeDerivative(t, root, "a.jpg", base, 10)
+	makeDerivative(t, root, "b.jpg", base.Add(1*time.Second), 10)
+
+	c := &Cache{MaxBytes: 15, MaxEntries: 100}
+	c.entries = []*cacheEntry{
+		{path: pathA, bytes: 10, modNano: base.UnixNano()},
+		{path: filepath.Join(root, "b.jpg"), bytes: 10, modNano: base.Add(1 * time.Second).UnixNano()},
+	}
+
+	c.evictLocked()
+
+	if len(c.entries) != 1 {
+		t.Fatalf("expected 1 entry to survive, got %d", len(c.entries))
+	}
+	if _, err := os.Stat(pathA); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest derivative %q to be evicted", pathA)
+	}
+}
+
+func TestCacheEvictLockedByMaxEntries(t *testing.T) {
+	root, err := ioutil.TempDir("", "imagecache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	base := time.Unix(1700000000, 0)
+	pathA := makeDerivative(t, root, "a.jpg", base, 10)
+	pathB := makeDerivative(t, root, "b.jpg", base.Add(1*time.Second), 10)
+	pathC := makeDerivative(t, root, "c.jpg", base.Add(2*time.Second), 10)
+
+	c := &Cache{MaxBytes: 1 << 30, MaxEntries: 2}
+	c.entries = []*cacheEntry{
+		{path: pathC, bytes: 10, modNano: base.Add(2 * time.Second).UnixNano()},
+		{path: pathA, bytes: 10, modNano: base.UnixNano()},
+		{path: pathB, bytes: 10, modNano: base.Add(1 * time.Second).UnixNano()},
+	}
+
+	c.evictLocked()
+
+	if len(c.entries) != 2 {
+		t.Fatalf("expected 2 entries to survive, got %d", len(c.entries))
+	}
+	if _, err := os.Stat(pathA); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest derivative %q to be evicted", pathA)
+	}
+	if _, err := os.Stat(pathB); err != nil {
+		t.Fatalf("expected %q to survive: %v", pathB, err)
+	}
+	if _, err := os.Stat(pathC); err != nil {
+		t.Fatalf("expected %q to survive: %v", pathC, err)
+	}
+}
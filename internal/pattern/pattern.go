@@ -0,0 +1,184 @@
+// Package pattern implements gitignore-style include/exclude matching,
+// modelled on the per-directory ".gitignore" files used by git itself:
+// "!"-negation, "**" recursion, trailing-slash directory-only matches,
+// and last-match-wins evaluation order.
+package pattern
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchResult is the outcome of evaluating a path against a Matcher.
+type MatchResult int
+
+const (
+	// NoMatch means no pattern matched the path.
+	NoMatch MatchResult = iota
+
+	// Exclude means the path should be omitted.
+	Exclude
+
+	// Include means the path should be kept, overriding an earlier Exclude.
+	Include
+)
+
+// Matcher decides whether a path should be included or excluded.
+type Matcher interface {
+	Match(path []string, isDir bool) MatchResult
+}
+
+// Pattern is a single compiled gitignore-style line.
+type Pattern struct {
+	negated bool
+	dirOnly bool
+
+	// exact matches the pattern itself. descendant additionally matches
+	// paths nested below it, and is only consulted when dirOnly is set
+	// (a directory-only pattern also excludes everything underneath).
+	exact      *regexp.Regexp
+	descendant *regexp.Regexp
+}
+
+// ParsePattern compiles a single gitignore-style line into a Pattern.
+// Blank lines and comments (lines starting with "#") return nil.
+func ParsePattern(line string) *Pattern {
+
+	line = strings.TrimRight(line, "\r\n")
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return nil
+	}
+
+	p := &Pattern{}
+
+	if strings.HasPrefix(trimmed, "!") {
+		p.negated = true
+		trimmed = trimmed[1:]
+	}
+
+	if strings.HasSuffix(trimmed, "/") {
+		p.dirOnly = true
+		trimmed = strings.TrimSuffix(trimmed, "/")
+	}
+
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+
+	core := compileCore(trimmed, anchored)
+	p.exact = regexp.MustCompile(core + "$")
+	if p.dirOnly {
+		p.descendant = regexp.MustCompile(core + "/.*$")
+	}
+
+	return p
+}
+
+// ParsePatterns compiles every line of lines into Patterns, skipping
+// blank lines and comments.
+func ParsePatterns(lines []string) []*Pattern {
+	patterns := make([]*Pattern, 0, len(lines))
+	for _, line := range lines {
+		if p := ParsePattern(line); p != nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// specialChars are regexp metacharacters that must be escaped when they
+// appear literally in a gitignore glob.
+const specialChars = `\.+()|[]{}^$`
+
+// compileCore translates a gitignore glob into the body of an anchored
+// regexp (everything between "^" and the final "$"). When anchored is
+// false the pattern may match starting at any path segment.
+func compileCore(glob string, anchored bool) string {
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(?:.*/)?")
+	}
+
+	// A bare "**" segment matches everything recursively; left to the
+	// per-character loop below it would degrade into two adjacent
+	// "[^/]*" tokens, which (unlike ".*") can never cross a "/".
+	if glob == "**" {
+		sb.WriteString(".*")
+	} else {
+		runes := []rune(glob)
+		for i := 0; i < len(runes); i++ {
+			rest := string(runes[i:])
+			switch {
+			case strings.HasPrefix(rest, "**/"):
+				sb.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(rest, "/**"):
+				sb.WriteString("(?:/.*)?")
+				i += 2
+			case runes[i] == '*':
+				sb.WriteString("[^/]*")
+			case runes[i] == '?':
+				sb.WriteString("[^/]")
+			case strings.ContainsRune(specialChars, runes[i]):
+				sb.WriteString(`\` + string(runes[i]))
+			default:
+				sb.WriteRune(runes[i])
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// Match reports whether p matches path, honouring its directory-only and
+// negation flags. A dirOnly pattern matches either the directory itself
+// (when isDir is true) or anything nested below it (regardless of
+// isDir, since a file inside an ignored directory is ignored too).
+func (p *Pattern) Match(path []string, isDir bool) MatchResult {
+
+	joined := strings.Join(path, "/")
+
+	var matched bool
+	if p.dirOnly {
+		// A non-directory path can never be the ignored directory
+		// itself; only "descendant" can apply. Checking "exact" here
+		// too would let its unanchored "(?:.*/)?" prefix swallow part
+		// of the path and wrongly treat a nested file as a match of
+		// the directory pattern itself.
+		matched = (isDir && p.exact.MatchString(joined)) || p.descendant.MatchString(joined)
+	} else {
+		matched = p.exact.MatchString(joined)
+	}
+
+	if !matched {
+		return NoMatch
+	}
+
+	if p.negated {
+		return Include
+	}
+	return Exclude
+}
+
+// patterns is a Matcher that evaluates an ordered list of Patterns,
+// where later matches override earlier ones.
+type patterns []*Pattern
+
+// NewMatcher returns a Matcher that evaluates ps in order, last-match-wins.
+func NewMatcher(ps []*Pattern) Matcher {
+	return patterns(ps)
+}
+
+// Match evaluates every pattern in order, returning the result of the
+// last one that matched, or NoMatch if none did.
+func (ps patterns) Match(path []string, isDir bool) MatchResult {
+	result := NoMatch
+	for _, p := range ps {
+		if r := p.Match(path, isDir); r != NoMatch {
+			result = r
+		}
+	}
+	return result
+}
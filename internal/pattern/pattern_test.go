@@ -0,0 +1,152 @@
+package pattern
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    []string
+		isDir   bool
+		want    MatchResult
+	}{
+		{
+			name:    "non-anchored glob matches at any depth",
+			pattern: "*.png",
+			path:    []string{"a", "b.png"},
+			want:    Exclude,
+		},
+		{
+			name:    "non-anchored glob does not cross a path separator",
+			pattern: "*.png",
+			path:    []string{"a.pngx"},
+			want:    NoMatch,
+		},
+		{
+			name:    "anchored pattern only matches from the ignore file's directory",
+			pattern: "src/build",
+			path:    []string{"src", "build"},
+			want:    Exclude,
+		},
+		{
+			name:    "anchored pattern does not match a same-named file elsewhere",
+			pattern: "src/build",
+			path:    []string{"other", "src", "build"},
+			want:    NoMatch,
+		},
+		{
+			name:    "dirOnly pattern excludes the directory itself",
+			pattern: "docs/",
+			path:    []string{"docs"},
+			isDir:   true,
+			want:    Exclude,
+		},
+		{
+			name:    "dirOnly pattern excludes files under the directory",
+			pattern: "docs/",
+			path:    []string{"docs", "readme.txt"},
+			want:    Exclude,
+		},
+		{
+			name:    "non-dirOnly pattern matches a file or directory of the same name",
+			pattern: "docs",
+			path:    []string{"docs"},
+			want:    Exclude,
+		},
+		{
+			name:    "dirOnly pattern does not match a plain file of the same name",
+			pattern: "docs/",
+			path:    []string{"docs"},
+			isDir:   false,
+			want:    NoMatch,
+		},
+		{
+			name:    "dirOnly wildcard pattern excludes a nested file, not just the directory itself",
+			pattern: "*/",
+			path:    []string{"a", "b", "c"},
+			isDir:   false,
+			want:    Exclude,
+		},
+		{
+			name:    "bare double-star matches every path, including nested ones",
+			pattern: "**",
+			path:    []string{"a", "b", "c.txt"},
+			want:    Exclude,
+		},
+		{
+			name:    "leading double-star-slash matches any depth",
+			pattern: "**/*.png",
+			path:    []string{"a", "b", "c.png"},
+			want:    Exclude,
+		},
+		{
+			name:    "trailing slash-double-star matches everything below the anchor",
+			pattern: "build/**",
+			path:    []string{"build", "a", "b.o"},
+			want:    Exclude,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ParsePattern(tt.pattern)
+			if p == nil {
+				t.Fatalf("ParsePattern(%q) returned nil", tt.pattern)
+			}
+			got := p.Match(tt.path, tt.isDir)
+			if got != tt.want {
+				t.Errorf("ParsePattern(%q).Match(%v, isDir=%v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherNegationIsLastMatchWins(t *testing.T) {
+	matcher := NewMatcher(ParsePatterns([]string{
+		"*.png",
+		"!keep.png",
+	}))
+
+	if got := matcher.Match([]string{"image.png"}, false); got != Exclude {
+		t.Errorf("image.png: got %v, want Exclude", got)
+	}
+
+	if got := matcher.Match([]string{"keep.png"}, false); got != Include {
+		t.Errorf("keep.png: got %v, want Include (negation should win the last match)", got)
+	}
+
+	if got := matcher.Match([]string{"readme.md"}, false); got != NoMatch {
+		t.Errorf("readme.md: got %v, want NoMatch", got)
+	}
+}
+
+func TestMatcherDirOnlyNegationOnlyAppliesToDirectories(t *testing.T) {
+	matcher := NewMatcher(ParsePatterns([]string{
+		"thumbs",
+		"!thumbs/keep/",
+	}))
+
+	// "thumbs/keep" is a directory: the negated dirOnly pattern applies
+	// and should win over the broader exclude.
+	if got := matcher.Match([]string{"thumbs", "keep"}, true); got != Include {
+		t.Errorf("thumbs/keep (dir): got %v, want Include", got)
+	}
+
+	// A plain file named "keep" directly under "thumbs" is not a
+	// directory, so the dirOnly negation does not apply.
+	if got := matcher.Match([]string{"thumbs"}, false); got != Exclude {
+		t.Errorf("thumbs (file): got %v, want Exclude", got)
+	}
+}
+
+func TestParsePatternSkipsBlankLinesAndComments(t *testing.T) {
+	if p := ParsePattern(""); p != nil {
+		t.Errorf("expected nil for blank line, got %+v", p)
+	}
+	if p := ParsePattern("   "); p != nil {
+		t.Errorf("expected nil for whitespace-only line, got %+v", p)
+	}
+	if p := ParsePattern("# a comment"); p != nil {
+		t.Errorf("expected nil for comment line, got %+v", p)
+	}
+}
@@ -0,0 +1,18 @@
+// Package safepath guards against path-traversal from
+// attacker-controlled request parameters.
+package safepath
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// UnderRoot reports whether path is root itself or lies somewhere below
+// it, rejecting "../" escapes.
+func UnderRoot(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (!strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel))
+}